@@ -0,0 +1,1464 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/copilot-cli/internal/pkg/aws/cloudwatchlogs"
+	"github.com/aws/copilot-cli/internal/pkg/aws/s3"
+	"github.com/aws/copilot-cli/internal/pkg/aws/sessions"
+	"github.com/aws/copilot-cli/internal/pkg/config"
+	"github.com/aws/copilot-cli/internal/pkg/deploy"
+	"github.com/aws/copilot-cli/internal/pkg/term/color"
+	"github.com/aws/copilot-cli/internal/pkg/term/prompt"
+	"github.com/aws/copilot-cli/internal/pkg/term/selector"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const (
+	svcLogAppNamePrompt     = "Which application does your service belong to?"
+	svcLogAppNameHelpPrompt = "An application groups all of your environments together."
+	svcLogNamePrompt        = "Which service's logs would you like to show?"
+	svcLogNameHelpPrompt    = "The logs command lists out the last 10 log messages for this service."
+)
+
+const (
+	logGroupNamePattern = "/copilot/%s-%s-%s"
+
+	// logStreamNameLen is the number of characters from a log stream's name
+	// that we show to keep each line from wrapping the terminal.
+	logStreamNameLen = 25
+
+	defaultServiceLogLimit = 10
+
+	// firelensLogRouterPrefix is the log stream prefix used by the Firelens
+	// sidecar that ships application logs alongside the task's own logs.
+	firelensLogRouterPrefix = "firelens_log_router/"
+
+	// defaultQueryPollInterval is how long Execute waits between polling a
+	// CloudWatch Logs Insights query for completion.
+	defaultQueryPollInterval = 1 * time.Second
+)
+
+// logLevel is a normalized severity extracted from a structured (JSON) log line.
+type logLevel string
+
+const (
+	logLevelDebug logLevel = "debug"
+	logLevelInfo  logLevel = "info"
+	logLevelWarn  logLevel = "warn"
+	logLevelError logLevel = "error"
+	logLevelFatal logLevel = "fatal"
+)
+
+// levelColor maps a normalized severity to the color it should be rendered in.
+var levelColor = map[logLevel]func(string) string{
+	logLevelDebug: color.Faint,
+	logLevelInfo:  color.Emphasize,
+	logLevelWarn:  color.Warning,
+	logLevelError: color.Error,
+	logLevelFatal: color.Error,
+}
+
+// jsonLevelFields are the JSON keys this command checks, in order, when
+// trying to identify the severity of a structured log line.
+var jsonLevelFields = []string{"level", "severity", "@level"}
+
+// levelHeuristics catches the unstructured access-log style fixtures the
+// existing tests exercise, where no JSON level field is present.
+var levelHeuristics = []struct {
+	re    *regexp.Regexp
+	level logLevel
+}{
+	{regexp.MustCompile(`FATA`), logLevelFatal},
+	{regexp.MustCompile(`WARN`), logLevelWarn},
+	{regexp.MustCompile(`ERRO`), logLevelError},
+	{regexp.MustCompile(`INFO`), logLevelInfo},
+	{regexp.MustCompile(`DEBU`), logLevelDebug},
+}
+
+var logLevelRank = map[logLevel]int{
+	logLevelDebug: 0,
+	logLevelInfo:  1,
+	logLevelWarn:  2,
+	logLevelError: 3,
+	logLevelFatal: 4,
+}
+
+// svcLogsVars contains the user-provided flags and arguments for the svc logs command.
+type svcLogsVars struct {
+	follow           bool
+	limit            int
+	envName          string
+	humanStartTime   string
+	humanEndTime     string
+	since            time.Duration
+	svcName          string
+	shouldOutputJSON bool
+
+	// container filters the stream to a single sidecar (e.g. the Firelens
+	// log router isolates one application container's output).
+	container string
+	// level only shows events whose detected severity is at or above this level.
+	level string
+	// grep only shows events whose message matches this regular expression.
+	grep string
+
+	// queryString is a CloudWatch Logs Insights query, provided inline.
+	queryString string
+	// queryFile is a path to a file containing a CloudWatch Logs Insights query.
+	queryFile string
+
+	// allEnvs fans the tail out across every environment the service is
+	// deployed to, merging events by timestamp instead of requiring the
+	// user to pick a single environment.
+	allEnvs bool
+	// envNames, when non-empty, is the set of environments to tail. No flag
+	// sets it, so only tests seed it directly; otherwise resolveEnvNames
+	// derives the set from allEnvs or envName.
+	envNames []string
+
+	// outputFile, maxSizeMB and maxFiles configure a rotating file sink.
+	// Only one of outputFile / exportS3 may be used.
+	outputFile string
+	maxSizeMB  int
+	maxFiles   int
+	// exportS3 is a s3://bucket/prefix destination events are batched and
+	// uploaded to as newline-delimited JSON, partitioned by hour.
+	exportS3 string
+
+	// highlightAnomalies turns on rolling-baseline anomaly detection in
+	// --follow mode: bursts of events (or error-level lines) well above a
+	// log stream's recent norm get a banner printed before the events that
+	// triggered them. window and sigma tune the baseline; both are ignored
+	// unless highlightAnomalies is set.
+	highlightAnomalies bool
+	window             time.Duration
+	sigma              float64
+
+	*GlobalOpts
+}
+
+// svcLogsOpts represents the configuration needed to show logs in a service.
+type svcLogsOpts struct {
+	svcLogsVars
+
+	configStore store
+	deployStore deployedEnvLister
+	sel         deploySelector
+
+	startTime *int64
+	endTime   *int64
+	// minLevel is parsed once from svcLogsVars.level in Validate so Execute
+	// doesn't re-validate the flag value on every page of events.
+	minLevel *logLevel
+	grepRe   *regexp.Regexp
+
+	initCwLogsSvc func(*svcLogsOpts, string) error // Overridden in tests.
+	cwlogsSvc     map[string]cwlogService
+	w             logSink
+	fs            afero.Fs
+
+	// anomalyDetector is non-nil only when highlightAnomalies is set; it's
+	// initialized once in Execute so its rolling baseline persists across
+	// the whole --follow session instead of resetting every page.
+	anomalyDetector *anomalyDetector
+
+	// queryPollInterval and sleep let tests drive the Log Insights polling
+	// loop in executeQuery without actually waiting in real time.
+	queryPollInterval time.Duration
+	sleep             func(time.Duration)
+
+	newS3Uploader func() (s3Uploader, error) // Overridden in tests.
+}
+
+// deployedEnvLister wraps the subset of the deploy store this command needs
+// to resolve which environments a service is actually deployed to, as
+// opposed to every environment configured for the app.
+type deployedEnvLister interface {
+	ListDeployedEnvironments(appName, svcName string) ([]string, error)
+}
+
+// cwlogService wraps the subset of the CloudWatch Logs client this command needs.
+type cwlogService interface {
+	TaskLogEvents(logGroupName string, streamLastEventTime map[string]int64, opts ...cloudwatchlogs.GetLogEventsOpts) (*cloudwatchlogs.LogEventsOutput, error)
+	// StartLogInsightsQuery submits a CloudWatch Logs Insights query over the
+	// given log group and time range and returns the query's ID.
+	StartLogInsightsQuery(logGroupName, queryString string, startTime, endTime int64) (string, error)
+	// GetLogInsightsQueryResults returns the current status and, once the
+	// query is Complete, the rows for a previously started query.
+	GetLogInsightsQueryResults(queryID string) (*cloudwatchlogs.QueryResultsOutput, error)
+}
+
+func newSvcLogOpts(vars svcLogsVars) (*svcLogsOpts, error) {
+	configStore, err := config.NewStore()
+	if err != nil {
+		return nil, fmt.Errorf("connect to config store: %w", err)
+	}
+	deployStore, err := deploy.NewStore(configStore)
+	if err != nil {
+		return nil, fmt.Errorf("connect to deploy store: %w", err)
+	}
+	opts := &svcLogsOpts{
+		svcLogsVars:       vars,
+		configStore:       configStore,
+		deployStore:       deployStore,
+		sel:               selector.NewDeploySelect(prompt.New(), configStore, deployStore),
+		cwlogsSvc:         make(map[string]cwlogService),
+		fs:                afero.NewOsFs(),
+		w:                 stdoutSink{os.Stdout},
+		queryPollInterval: defaultQueryPollInterval,
+		sleep:             time.Sleep,
+	}
+	opts.initCwLogsSvc = func(o *svcLogsOpts, envName string) error {
+		env, err := configStore.GetEnvironment(o.AppName(), envName)
+		if err != nil {
+			return fmt.Errorf("get environment %s: %w", envName, err)
+		}
+		sess, err := sessions.NewProvider().FromRole(env.ManagerRoleARN, env.Region)
+		if err != nil {
+			return fmt.Errorf("create session from role %s: %w", env.ManagerRoleARN, err)
+		}
+		o.cwlogsSvc[envName] = cloudwatchlogs.New(sess)
+		return nil
+	}
+	opts.newS3Uploader = func() (s3Uploader, error) {
+		sess, err := sessions.NewProvider().Default()
+		if err != nil {
+			return nil, fmt.Errorf("get default session: %w", err)
+		}
+		return s3.New(sess), nil
+	}
+	return opts, nil
+}
+
+// logSink is the destination svc logs events are rendered to. Besides the
+// default of writing to stdout, Execute can instead point this at a
+// rotating local file or a batched S3 export so a long-running
+// `--follow` session can persist its output.
+type logSink interface {
+	io.Writer
+}
+
+// stdoutSink is the default logSink. It deliberately does not forward
+// io.Closer so closeLogSink never closes the process' real stdout.
+type stdoutSink struct {
+	io.Writer
+}
+
+// closeLogSink flushes/closes a logSink if it supports doing so. Sinks like
+// stdoutSink and *bytes.Buffer in tests don't, and are left alone.
+func closeLogSink(w logSink) error {
+	closer, ok := w.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+// defaultFileSinkPerm is the mode used for --output-file and its rotated,
+// gzip-compressed siblings.
+const defaultFileSinkPerm = 0644
+
+// fileSink is a logSink that appends to a local file, rotating to a
+// gzip-compressed, numbered sibling (path.1.gz, path.2.gz, ...) once the
+// current file would exceed maxSizeBytes. Only the maxFiles most recent
+// rotated files are kept.
+type fileSink struct {
+	fs           afero.Fs
+	path         string
+	maxSizeBytes int64
+	maxFiles     int
+
+	f    afero.File
+	size int64
+}
+
+// newFileSink opens (or creates) path for appending and returns a fileSink
+// sized from its current contents, so re-running a command against an
+// existing --output-file keeps rotating from where it left off.
+func newFileSink(fs afero.Fs, path string, maxSizeBytes int64, maxFiles int) (*fileSink, error) {
+	f, err := fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileSinkPerm)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{
+		fs:           fs,
+		path:         path,
+		maxSizeBytes: maxSizeBytes,
+		maxFiles:     maxFiles,
+		f:            f,
+		size:         info.Size(),
+	}, nil
+}
+
+// Write appends p to the current file, rotating first if p would push the
+// file past maxSizeBytes.
+func (s *fileSink) Write(p []byte) (int, error) {
+	if s.maxSizeBytes > 0 && s.size > 0 && s.size+int64(len(p)) > s.maxSizeBytes {
+		if err := s.rotate(); err != nil {
+			return 0, fmt.Errorf("rotate %s: %w", s.path, err)
+		}
+	}
+	n, err := s.f.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// rotate gzip-compresses the current file to path.1.gz, shifting any
+// existing rotated files up by one (dropping the oldest past maxFiles),
+// then truncates path so writing can continue. maxFiles <= 0 keeps no
+// backups at all, matching logrotate's "rotate 0" semantics: the file is
+// simply truncated in place.
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if s.maxFiles > 0 {
+		if err := s.fs.Remove(s.rotatedName(s.maxFiles)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for i := s.maxFiles - 1; i >= 1; i-- {
+			if err := s.fs.Rename(s.rotatedName(i), s.rotatedName(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := s.gzipToRotated(); err != nil {
+			return err
+		}
+	}
+	f, err := s.fs.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileSinkPerm)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.size = 0
+	return nil
+}
+
+// rotatedName returns the path of the nth rotated, gzip-compressed backup.
+func (s *fileSink) rotatedName(n int) string {
+	return fmt.Sprintf("%s.%d.gz", s.path, n)
+}
+
+// gzipToRotated compresses the just-closed current file into path.1.gz and
+// removes the uncompressed original.
+func (s *fileSink) gzipToRotated() error {
+	src, err := s.fs.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := s.fs.OpenFile(s.rotatedName(1), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileSinkPerm)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return s.fs.Remove(s.path)
+}
+
+// Close closes the underlying file.
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
+
+// s3Uploader is the subset of the S3 upload client the S3 export sink needs.
+type s3Uploader interface {
+	// Upload uploads body to bucket under key.
+	Upload(bucket, key string, body io.Reader) error
+}
+
+// defaultS3SinkBatchBytes is how many bytes of newline-delimited JSON the S3
+// export sink buffers before flushing a batch to S3.
+const defaultS3SinkBatchBytes = 5 * 1024 * 1024
+
+// s3Sink is a logSink that batches events into newline-delimited JSON
+// objects and uploads a batch to S3 when the next write would push it past
+// batchBytes, and on Close, keyed
+// under a year=/month=/day=/hour= partition so the export can be queried
+// later with Athena or Logs Insights.
+type s3Sink struct {
+	uploader   s3Uploader
+	bucket     string
+	prefix     string
+	batchBytes int
+	now        func() time.Time
+
+	buf bytes.Buffer
+}
+
+// newS3Sink returns an s3Sink that uploads through uploader. now is injected
+// so tests can pin the partition a batch is keyed under.
+func newS3Sink(uploader s3Uploader, bucket, prefix string, batchBytes int, now func() time.Time) *s3Sink {
+	return &s3Sink{uploader: uploader, bucket: bucket, prefix: prefix, batchBytes: batchBytes, now: now}
+}
+
+// Write buffers p, flushing the current batch first if adding p would push it
+// past batchBytes.
+func (s *s3Sink) Write(p []byte) (int, error) {
+	if s.buf.Len() > 0 && s.buf.Len()+len(p) > s.batchBytes {
+		if err := s.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return s.buf.Write(p)
+}
+
+// flush uploads the buffered batch under a key partitioned by the current
+// UTC hour, then resets the buffer.
+func (s *s3Sink) flush() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	key := s.key()
+	if err := s.uploader.Upload(s.bucket, key, bytes.NewReader(s.buf.Bytes())); err != nil {
+		return fmt.Errorf("upload batch to s3://%s/%s: %w", s.bucket, key, err)
+	}
+	s.buf.Reset()
+	return nil
+}
+
+// key builds the partitioned object key for the batch about to be flushed.
+func (s *s3Sink) key() string {
+	t := s.now().UTC()
+	return fmt.Sprintf("%syear=%04d/month=%02d/day=%02d/hour=%02d/%d.ndjson",
+		s.prefix, t.Year(), t.Month(), t.Day(), t.Hour(), t.UnixNano())
+}
+
+// Close flushes any batch still buffered but not yet uploaded.
+func (s *s3Sink) Close() error {
+	return s.flush()
+}
+
+// parseS3URI splits a s3://bucket/prefix URI into its bucket and prefix,
+// normalizing the prefix (if any) to always end in "/" so key() can just
+// concatenate it with the partition path.
+func parseS3URI(uri string) (bucket, prefix string, err error) {
+	const s3Scheme = "s3://"
+	if !strings.HasPrefix(uri, s3Scheme) {
+		return "", "", fmt.Errorf("invalid argument %s for \"--export-s3\" flag: must be a s3:// URI", uri)
+	}
+	rest := strings.TrimPrefix(uri, s3Scheme)
+	parts := strings.SplitN(rest, "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("invalid argument %s for \"--export-s3\" flag: missing bucket name", uri)
+	}
+	bucket = parts[0]
+	if len(parts) == 2 && parts[1] != "" {
+		prefix = strings.TrimSuffix(parts[1], "/") + "/"
+	}
+	return bucket, prefix, nil
+}
+
+// anomalyBucketWidth is the width of the buckets an anomalyDetector groups
+// events into before comparing a stream's recent rate against its baseline.
+const anomalyBucketWidth = 10 * time.Second
+
+// defaultAnomalyWindow is the default amount of trailing history
+// --highlight-anomalies' rolling baseline is built from.
+const defaultAnomalyWindow = 5 * time.Minute
+
+// defaultAnomalySigma is the default number of standard deviations above the
+// rolling mean a bucket's event count must reach to be flagged.
+const defaultAnomalySigma = 3.0
+
+// errorBurstWindow and errorBurstThreshold configure a second, simpler
+// detector that runs alongside the rolling mean/stddev baseline: more than
+// errorBurstThreshold error-or-above events from a single stream within a
+// trailing errorBurstWindow is reported once as a burst.
+const (
+	errorBurstWindow    = 1 * time.Minute
+	errorBurstThreshold = 10
+)
+
+// anomalyBanner is a single banner an anomalyDetector wants rendered before
+// the event that triggered it.
+type anomalyBanner struct {
+	stream  string
+	message string
+}
+
+// welford incrementally tracks the rolling mean and variance of a stream of
+// samples (Welford's online algorithm), so a sliding window of bucket
+// counts can be maintained without replaying the whole window on every
+// update.
+type welford struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+// add folds x into the running mean/variance.
+func (w *welford) add(x float64) {
+	w.n++
+	delta := x - w.mean
+	w.mean += delta / float64(w.n)
+	w.m2 += delta * (x - w.mean)
+}
+
+// remove reverses add for a sample that's aging out of the window. It
+// assumes x was previously folded in via add and w.n > 0.
+func (w *welford) remove(x float64) {
+	if w.n <= 1 {
+		*w = welford{}
+		return
+	}
+	n := float64(w.n)
+	meanOld := (w.mean*n - x) / (n - 1)
+	w.m2 -= (x - w.mean) * (x - meanOld)
+	w.mean = meanOld
+	w.n--
+}
+
+// stddev returns the population standard deviation of the samples currently
+// folded in, or 0 until there are at least two.
+func (w *welford) stddev() float64 {
+	if w.n < 2 {
+		return 0
+	}
+	return math.Sqrt(w.m2 / float64(w.n))
+}
+
+// streamAnomalyState is the per-log-stream state an anomalyDetector tracks:
+// a Welford baseline fed by a ring of completed bucket counts, the
+// in-progress bucket, and a trailing-window error timestamp queue for
+// burst detection.
+type streamAnomalyState struct {
+	baseline      welford
+	buckets       []int     // ring of completed bucket counts still in the window
+	bucketStart   time.Time // start of the in-progress bucket
+	bucketCount   int
+	flaggedBucket bool // whether the in-progress bucket already triggered a banner
+
+	errorTimes []time.Time // trailing error/fatal timestamps, oldest first
+	burst      bool        // whether the stream is currently in a reported burst
+}
+
+// anomalyDetector maintains a per-log-stream rolling baseline of event
+// counts over anomalyBucketWidth buckets and flags buckets that spike well
+// above it, plus a trailing-window burst detector for error-level lines.
+type anomalyDetector struct {
+	sigma         float64
+	windowBuckets int
+	streams       map[string]*streamAnomalyState
+}
+
+// newAnomalyDetector returns a detector whose baseline is built from the
+// trailing window of history and that flags buckets sigma standard
+// deviations above the rolling mean.
+func newAnomalyDetector(window time.Duration, sigma float64) *anomalyDetector {
+	windowBuckets := int(window / anomalyBucketWidth)
+	if windowBuckets < 1 {
+		windowBuckets = 1
+	}
+	return &anomalyDetector{
+		sigma:         sigma,
+		windowBuckets: windowBuckets,
+		streams:       make(map[string]*streamAnomalyState),
+	}
+}
+
+// observe records event against its stream's rolling baseline and burst
+// counter, returning zero or more banners to render before it.
+func (d *anomalyDetector) observe(event *cloudwatchlogs.Event) []anomalyBanner {
+	s, ok := d.streams[event.LogStreamName]
+	if !ok {
+		s = &streamAnomalyState{}
+		d.streams[event.LogStreamName] = s
+	}
+	t := time.Unix(0, event.Timestamp*int64(time.Millisecond))
+
+	var banners []anomalyBanner
+	banners = append(banners, d.observeRate(s, event.LogStreamName, t)...)
+	banners = append(banners, d.observeBurst(s, event, t)...)
+	return banners
+}
+
+// observeRate advances s's bucket ring to t, folding any buckets that just
+// completed (including empty ones skipped over a gap) into the baseline,
+// then compares the in-progress bucket's running count to the baseline.
+func (d *anomalyDetector) observeRate(s *streamAnomalyState, stream string, t time.Time) []anomalyBanner {
+	eventBucketStart := t.Truncate(anomalyBucketWidth)
+	if s.bucketStart.IsZero() {
+		s.bucketStart = eventBucketStart
+	}
+	for eventBucketStart.After(s.bucketStart) {
+		d.rollBucket(s)
+		s.bucketStart = s.bucketStart.Add(anomalyBucketWidth)
+		s.bucketCount = 0
+		s.flaggedBucket = false
+	}
+	s.bucketCount++
+
+	if s.flaggedBucket || s.baseline.n < 2 {
+		return nil
+	}
+	threshold := s.baseline.mean + d.sigma*s.baseline.stddev()
+	if float64(s.bucketCount) <= threshold {
+		return nil
+	}
+	s.flaggedBucket = true
+	return []anomalyBanner{{
+		stream: stream,
+		message: fmt.Sprintf("anomaly: %s logged %d events in the last %s (baseline %.1f±%.1f)",
+			stream, s.bucketCount, anomalyBucketWidth, s.baseline.mean, s.baseline.stddev()),
+	}}
+}
+
+// rollBucket folds the in-progress bucket into the baseline, aging the
+// oldest bucket out of the window once it's full.
+func (d *anomalyDetector) rollBucket(s *streamAnomalyState) {
+	s.baseline.add(float64(s.bucketCount))
+	s.buckets = append(s.buckets, s.bucketCount)
+	if len(s.buckets) > d.windowBuckets {
+		s.baseline.remove(float64(s.buckets[0]))
+		s.buckets = s.buckets[1:]
+	}
+}
+
+// observeBurst tracks a trailing errorBurstWindow of error-or-above
+// timestamps per stream, reporting once each time the count crosses
+// errorBurstThreshold and resetting once it falls back below it.
+func (d *anomalyDetector) observeBurst(s *streamAnomalyState, event *cloudwatchlogs.Event, t time.Time) []anomalyBanner {
+	lvl, ok := detectLogLevel(event.Message)
+	if !ok || logLevelRank[lvl] < logLevelRank[logLevelError] {
+		return nil
+	}
+	s.errorTimes = append(s.errorTimes, t)
+	cutoff := t.Add(-errorBurstWindow)
+	i := 0
+	for i < len(s.errorTimes) && s.errorTimes[i].Before(cutoff) {
+		i++
+	}
+	s.errorTimes = s.errorTimes[i:]
+
+	if len(s.errorTimes) <= errorBurstThreshold {
+		s.burst = false
+		return nil
+	}
+	if s.burst {
+		return nil
+	}
+	s.burst = true
+	return []anomalyBanner{{
+		stream: event.LogStreamName,
+		message: fmt.Sprintf("anomaly: %d error-level events from %s in the last %s",
+			len(s.errorTimes), event.LogStreamName, errorBurstWindow),
+	}}
+}
+
+// Validate returns an error if the values provided by flags are invalid.
+func (o *svcLogsOpts) Validate() error {
+	if o.AppName() != "" {
+		if _, err := o.configStore.GetApplication(o.AppName()); err != nil {
+			return err
+		}
+	}
+	if o.since != 0 && o.humanStartTime != "" {
+		return fmt.Errorf("only one of --since or --start-time may be used")
+	}
+	if o.follow && o.humanEndTime != "" {
+		return fmt.Errorf("only one of --follow or --end-time may be used")
+	}
+	if o.since != 0 {
+		if o.since < 0 {
+			return fmt.Errorf("--since must be greater than 0")
+		}
+		startTime := time.Now().Add(-o.since).Unix() * 1000
+		o.startTime = &startTime
+	}
+	if o.humanStartTime != "" {
+		startTime, err := parseFlagTime(o.humanStartTime, "start-time")
+		if err != nil {
+			return err
+		}
+		o.startTime = startTime
+	}
+	if o.humanEndTime != "" {
+		endTime, err := parseFlagTime(o.humanEndTime, "end-time")
+		if err != nil {
+			return err
+		}
+		o.endTime = endTime
+	}
+	if o.level != "" {
+		lvl, err := normalizeLogLevel(o.level)
+		if err != nil {
+			return err
+		}
+		o.minLevel = &lvl
+	}
+	if o.grep != "" {
+		re, err := regexp.Compile(o.grep)
+		if err != nil {
+			return fmt.Errorf("invalid argument %s for \"--grep\" flag: %w", o.grep, err)
+		}
+		o.grepRe = re
+	}
+	if o.queryString != "" && o.queryFile != "" {
+		return fmt.Errorf("only one of --query-string or --query-file may be used")
+	}
+	if o.allEnvs && (o.queryString != "" || o.queryFile != "") {
+		return fmt.Errorf("--all-envs cannot be combined with --query-string or --query-file")
+	}
+	if o.outputFile != "" && o.exportS3 != "" {
+		return fmt.Errorf("only one of --output-file or --export-s3 may be used")
+	}
+	if o.highlightAnomalies && !o.follow {
+		return fmt.Errorf("--highlight-anomalies requires --follow")
+	}
+	if o.limit < 1 || o.limit > 10000 {
+		return fmt.Errorf("--limit %d is out-of-bounds, value must be between 1 and 10000", o.limit)
+	}
+	return nil
+}
+
+// parseFlagTime parses value as an RFC3339 timestamp and converts it to
+// CloudWatch Logs' millisecond-since-epoch representation.
+func parseFlagTime(value, flagName string) (*int64, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument %s for \"--%s\" flag: reading time value %s: %w", value, flagName, value, err)
+	}
+	ms := t.Unix() * 1000
+	return &ms, nil
+}
+
+func normalizeLogLevel(raw string) (logLevel, error) {
+	switch strings.ToLower(raw) {
+	case string(logLevelDebug):
+		return logLevelDebug, nil
+	case string(logLevelInfo):
+		return logLevelInfo, nil
+	case string(logLevelWarn):
+		return logLevelWarn, nil
+	case string(logLevelError):
+		return logLevelError, nil
+	case string(logLevelFatal):
+		return logLevelFatal, nil
+	default:
+		return "", fmt.Errorf("invalid argument %s for \"--level\" flag: level must be one of debug, info, warn, error, fatal", raw)
+	}
+}
+
+// Ask asks for fields that are required but not passed in.
+func (o *svcLogsOpts) Ask() error {
+	if o.AppName() == "" {
+		app, err := o.sel.Application(svcLogAppNamePrompt, svcLogAppNameHelpPrompt)
+		if err != nil {
+			return fmt.Errorf("select application: %w", err)
+		}
+		o.appName = app
+	}
+	if o.allEnvs {
+		if o.svcName == "" {
+			return fmt.Errorf("--svc must be specified when --all-envs is set")
+		}
+		return nil
+	}
+	deployedService, err := o.sel.DeployedService(svcLogNamePrompt, svcLogNameHelpPrompt, o.AppName(), selector.WithEnv(o.envName), selector.WithSvc(o.svcName))
+	if err != nil {
+		return fmt.Errorf("select deployed services for application %s: %w", o.AppName(), err)
+	}
+	o.svcName = deployedService.Svc
+	o.envName = deployedService.Env
+	return nil
+}
+
+// Execute outputs logs of the service.
+func (o *svcLogsOpts) Execute() error {
+	if err := o.initSink(); err != nil {
+		return err
+	}
+	defer closeLogSink(o.w)
+	if o.highlightAnomalies {
+		o.anomalyDetector = newAnomalyDetector(o.anomalyWindow(), o.anomalySigma())
+	}
+	envNames, err := o.resolveEnvNames()
+	if err != nil {
+		return err
+	}
+	for _, env := range envNames {
+		if err := o.initCwLogsSvc(o, env); err != nil {
+			return err
+		}
+	}
+	if o.queryString != "" || o.queryFile != "" {
+		logGroupName := fmt.Sprintf(logGroupNamePattern, o.AppName(), envNames[0], o.svcName)
+		return o.executeQuery(logGroupName, envNames[0])
+	}
+	if len(envNames) == 1 {
+		return o.tailEnv(envNames[0])
+	}
+	return o.tailMultiEnv(envNames)
+}
+
+// initSink points o.w at the sink selected by --output-file/--export-s3, if
+// any (Validate already rejects setting both). --export-s3 also turns on JSON
+// rendering, so each exported batch is newline-delimited JSON. Tests (and the
+// zero-flag default from newSvcLogOpts) leave o.w as-is.
+func (o *svcLogsOpts) initSink() error {
+	switch {
+	case o.outputFile != "":
+		sink, err := newFileSink(o.fs, o.outputFile, int64(o.maxSizeMB)*1024*1024, o.maxFiles)
+		if err != nil {
+			return fmt.Errorf("open output file %s: %w", o.outputFile, err)
+		}
+		o.w = sink
+	case o.exportS3 != "":
+		bucket, prefix, err := parseS3URI(o.exportS3)
+		if err != nil {
+			return err
+		}
+		uploader, err := o.newS3Uploader()
+		if err != nil {
+			return err
+		}
+		o.w = newS3Sink(uploader, bucket, prefix, defaultS3SinkBatchBytes, time.Now)
+		o.shouldOutputJSON = true
+	}
+	return nil
+}
+
+// anomalyWindow returns --window, falling back to defaultAnomalyWindow when
+// Execute is driven directly (e.g. by tests) rather than through the built
+// cobra command, which otherwise supplies the flag's own default.
+func (o *svcLogsOpts) anomalyWindow() time.Duration {
+	if o.window <= 0 {
+		return defaultAnomalyWindow
+	}
+	return o.window
+}
+
+// anomalySigma returns --sigma, defaulting it the same way as anomalyWindow.
+func (o *svcLogsOpts) anomalySigma() float64 {
+	if o.sigma <= 0 {
+		return defaultAnomalySigma
+	}
+	return o.sigma
+}
+
+// maybeHighlightAnomalies feeds event through the anomaly detector, if
+// --highlight-anomalies is on, and renders any banners it returns before
+// the event itself is written.
+func (o *svcLogsOpts) maybeHighlightAnomalies(event *cloudwatchlogs.Event) error {
+	if o.anomalyDetector == nil {
+		return nil
+	}
+	for _, banner := range o.anomalyDetector.observe(event) {
+		if err := o.writeAnomalyBanner(banner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAnomalyBanner renders an anomaly banner: a synthetic "kind":"anomaly"
+// JSON event in --json mode (so structured consumers still see it), or a
+// colorized banner line in human mode.
+func (o *svcLogsOpts) writeAnomalyBanner(banner anomalyBanner) error {
+	if o.shouldOutputJSON {
+		data, err := json.Marshal(struct {
+			Kind    string `json:"kind"`
+			Stream  string `json:"stream"`
+			Message string `json:"message"`
+		}{Kind: "anomaly", Stream: banner.stream, Message: banner.message})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(o.w, "%s\n", data)
+		return err
+	}
+	_, err := fmt.Fprintln(o.w, color.Warning(banner.message))
+	return err
+}
+
+// resolveEnvNames returns the environments Execute should tail: every
+// environment the service is deployed to (--all-envs), the set seeded
+// directly on svcLogsVars (tests, or a future repeatable --env), or the
+// single environment chosen in Ask.
+func (o *svcLogsOpts) resolveEnvNames() ([]string, error) {
+	if o.allEnvs {
+		names, err := o.deployStore.ListDeployedEnvironments(o.AppName(), o.svcName)
+		if err != nil {
+			return nil, fmt.Errorf("list environments %s is deployed to: %w", o.svcName, err)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("service %s is not deployed to any environment in application %s", o.svcName, o.AppName())
+		}
+		names = append([]string(nil), names...)
+		sort.Strings(names)
+		return names, nil
+	}
+	if len(o.envNames) > 0 {
+		names := append([]string(nil), o.envNames...)
+		sort.Strings(names)
+		return names, nil
+	}
+	return []string{o.envName}, nil
+}
+
+// tailEnv tails a single environment's log group, the original svc logs behavior.
+func (o *svcLogsOpts) tailEnv(env string) error {
+	logGroupName := fmt.Sprintf(logGroupNamePattern, o.AppName(), env, o.svcName)
+	lastEventTime := make(map[string]int64)
+	for {
+		logEventsOutput, err := o.cwlogsSvc[env].TaskLogEvents(logGroupName, lastEventTime, o.logEventsOpts()...)
+		if err != nil {
+			return err
+		}
+		if err := o.writeEvents(logEventsOutput.Events); err != nil {
+			return err
+		}
+		if !o.follow || logEventsOutput.LastEventTime == nil {
+			return nil
+		}
+		lastEventTime = logEventsOutput.LastEventTime
+	}
+}
+
+// envEvent pairs a log event with the environment it was tailed from, so a
+// merged, interleaved stream can still be prefixed per line.
+type envEvent struct {
+	env   string
+	event *cloudwatchlogs.Event
+}
+
+// envEventHeap is a min-heap ordering envEvents by timestamp, breaking ties
+// by environment name so the merged stream is stable across runs.
+type envEventHeap []envEvent
+
+func (h envEventHeap) Len() int { return len(h) }
+func (h envEventHeap) Less(i, j int) bool {
+	if h[i].event.Timestamp != h[j].event.Timestamp {
+		return h[i].event.Timestamp < h[j].event.Timestamp
+	}
+	return h[i].env < h[j].env
+}
+func (h envEventHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *envEventHeap) Push(x interface{}) { *h = append(*h, x.(envEvent)) }
+func (h *envEventHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// mergeEnvEvents merges one page of events per environment into a single,
+// timestamp-ordered stream using a bounded heap (bounded by the page size
+// each TaskLogEvents call already returns).
+func mergeEnvEvents(perEnv map[string][]*cloudwatchlogs.Event, envOrder []string) []envEvent {
+	h := &envEventHeap{}
+	heap.Init(h)
+	for _, env := range envOrder {
+		for _, event := range perEnv[env] {
+			heap.Push(h, envEvent{env: env, event: event})
+		}
+	}
+	merged := make([]envEvent, 0, h.Len())
+	for h.Len() > 0 {
+		merged = append(merged, heap.Pop(h).(envEvent))
+	}
+	return merged
+}
+
+// tailMultiEnv fans TaskLogEvents out across envNames, keeping an
+// independent LastEventTime per environment so follow mode can interleave
+// new pages as they arrive from each one. An environment whose TaskLogEvents
+// call errors is excluded from later pages rather than aborting the whole
+// tail, since --all-envs can resolve environments a caller doesn't have
+// equal access/visibility into. Only if every environment fails outright
+// does tailMultiEnv report an error.
+func (o *svcLogsOpts) tailMultiEnv(envNames []string) error {
+	lastEventTime := make(map[string]map[string]int64, len(envNames))
+	active := make(map[string]bool, len(envNames))
+	for _, env := range envNames {
+		lastEventTime[env] = make(map[string]int64)
+		active[env] = true
+	}
+	succeeded := make(map[string]bool, len(envNames))
+	for {
+		perEnv := make(map[string][]*cloudwatchlogs.Event, len(envNames))
+		for _, env := range envNames {
+			if !active[env] {
+				continue
+			}
+			logGroupName := fmt.Sprintf(logGroupNamePattern, o.AppName(), env, o.svcName)
+			out, err := o.cwlogsSvc[env].TaskLogEvents(logGroupName, lastEventTime[env], o.logEventsOpts()...)
+			if err != nil {
+				active[env] = false
+				fmt.Fprintf(os.Stderr, "tail %s: %v, excluding it from the rest of this run\n", env, err)
+				continue
+			}
+			succeeded[env] = true
+			perEnv[env] = out.Events
+			if out.LastEventTime != nil {
+				lastEventTime[env] = out.LastEventTime
+			} else {
+				active[env] = false
+			}
+		}
+		for _, merged := range mergeEnvEvents(perEnv, envNames) {
+			if !o.matchesFilters(merged.event) {
+				continue
+			}
+			if err := o.maybeHighlightAnomalies(merged.event); err != nil {
+				return err
+			}
+			if err := o.writeEnvEvent(envNames, merged.env, merged.event); err != nil {
+				return err
+			}
+		}
+		if len(succeeded) == 0 {
+			return fmt.Errorf("tail any of environment(s) %s", strings.Join(envNames, ", "))
+		}
+		if !o.follow {
+			return nil
+		}
+		stillActive := false
+		for _, env := range envNames {
+			if active[env] {
+				stillActive = true
+			}
+		}
+		if !stillActive {
+			return nil
+		}
+	}
+}
+
+func (o *svcLogsOpts) logEventsOpts() []cloudwatchlogs.GetLogEventsOpts {
+	opts := []cloudwatchlogs.GetLogEventsOpts{cloudwatchlogs.WithLimit(o.limit)}
+	if o.startTime != nil {
+		opts = append(opts, cloudwatchlogs.WithStartTime(*o.startTime))
+	}
+	if o.endTime != nil {
+		opts = append(opts, cloudwatchlogs.WithEndTime(*o.endTime))
+	}
+	return opts
+}
+
+// executeQuery submits a CloudWatch Logs Insights query built from
+// --query-string/--query-file, polls it to completion, and streams the
+// resulting rows to o.w. This makes aggregate/analytics questions (counts,
+// percentiles, top-N paths) first-class instead of forcing users to shell
+// out to the AWS CLI. env is the environment Execute resolved the log group
+// from, not o.envName, since the latter is left unset by --all-envs.
+func (o *svcLogsOpts) executeQuery(logGroupName, env string) error {
+	queryString, err := o.resolveQueryString()
+	if err != nil {
+		return err
+	}
+	startTime, endTime := o.queryTimeRange()
+	queryID, err := o.cwlogsSvc[env].StartLogInsightsQuery(logGroupName, queryString, startTime, endTime)
+	if err != nil {
+		return fmt.Errorf("start log insights query: %w", err)
+	}
+	for {
+		results, err := o.cwlogsSvc[env].GetLogInsightsQueryResults(queryID)
+		if err != nil {
+			return fmt.Errorf("get log insights query results: %w", err)
+		}
+		switch results.Status {
+		case cloudwatchlogs.QueryStatusComplete:
+			return o.writeQueryResults(results)
+		case cloudwatchlogs.QueryStatusFailed, cloudwatchlogs.QueryStatusCancelled, cloudwatchlogs.QueryStatusTimeout:
+			return fmt.Errorf("query %s ended with status %s", queryID, results.Status)
+		}
+		o.sleep(o.queryPollInterval)
+	}
+}
+
+// resolveQueryString returns the query text from --query-string, or reads
+// it from --query-file.
+func (o *svcLogsOpts) resolveQueryString() (string, error) {
+	if o.queryString != "" {
+		return o.queryString, nil
+	}
+	contents, err := afero.ReadFile(o.fs, o.queryFile)
+	if err != nil {
+		return "", fmt.Errorf("read query file %s: %w", o.queryFile, err)
+	}
+	return string(contents), nil
+}
+
+// queryTimeRange converts the already-validated start/end/since flags into
+// the millisecond-since-epoch bounds StartLogInsightsQuery expects,
+// defaulting the end of the range to now.
+func (o *svcLogsOpts) queryTimeRange() (startTime, endTime int64) {
+	if o.startTime != nil {
+		startTime = *o.startTime
+	}
+	endTime = time.Now().Unix() * 1000
+	if o.endTime != nil {
+		endTime = *o.endTime
+	}
+	return startTime, endTime
+}
+
+// writeQueryResults renders Log Insights rows as a tab-separated table in
+// human mode, or as JSON in --json mode.
+func (o *svcLogsOpts) writeQueryResults(results *cloudwatchlogs.QueryResultsOutput) error {
+	if o.shouldOutputJSON {
+		data, err := json.Marshal(results.Results)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(o.w, "%s\n", data)
+		return err
+	}
+	for _, row := range results.Results {
+		var cells []string
+		for _, field := range row {
+			cells = append(cells, fmt.Sprintf("%s=%s", field.Field, field.Value))
+		}
+		if _, err := fmt.Fprintln(o.w, strings.Join(cells, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEvents filters events by --container/--level/--grep and renders the
+// survivors as either colorized human-readable lines or JSON lines.
+func (o *svcLogsOpts) writeEvents(events []*cloudwatchlogs.Event) error {
+	for _, event := range events {
+		if !o.matchesFilters(event) {
+			continue
+		}
+		if err := o.maybeHighlightAnomalies(event); err != nil {
+			return err
+		}
+		if o.shouldOutputJSON {
+			if err := o.writeEventJSON(event); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := o.writeEventHuman(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *svcLogsOpts) matchesFilters(event *cloudwatchlogs.Event) bool {
+	if o.container != "" {
+		name, ok := firelensContainerName(event.LogStreamName)
+		if !ok || name != o.container {
+			return false
+		}
+	}
+	if o.minLevel != nil {
+		lvl, ok := detectLogLevel(event.Message)
+		if !ok || logLevelRank[lvl] < logLevelRank[*o.minLevel] {
+			return false
+		}
+	}
+	if o.grepRe != nil && !o.grepRe.MatchString(event.Message) {
+		return false
+	}
+	return true
+}
+
+// firelensContainerName extracts the container name from a Firelens-routed
+// log stream name. Copilot names each container's stream
+// "firelensLogRouterPrefix<container-name>/<task-id>", mirroring the
+// "prefix/container-name/task-id" convention the awslogs driver itself
+// uses, so the container name is the path segment right after the prefix,
+// not whatever's left after stripping it. Streams that don't follow this
+// convention have no extractable container name.
+func firelensContainerName(logStreamName string) (string, bool) {
+	rest := strings.TrimPrefix(logStreamName, firelensLogRouterPrefix)
+	if rest == logStreamName {
+		return "", false
+	}
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// detectLogLevel tries to pull a severity out of a log line. It first looks
+// for the structured fields Firelens-shipped JSON lines commonly use, then
+// falls back to the same regex heuristics the plain-text fixtures rely on.
+func detectLogLevel(message string) (logLevel, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &fields); err == nil {
+		for _, key := range jsonLevelFields {
+			if raw, ok := fields[key]; ok {
+				if s, ok := raw.(string); ok {
+					if lvl, err := normalizeLogLevel(s); err == nil {
+						return lvl, true
+					}
+				}
+			}
+		}
+	}
+	for _, h := range levelHeuristics {
+		if h.re.MatchString(message) {
+			return h.level, true
+		}
+	}
+	return "", false
+}
+
+func (o *svcLogsOpts) writeEventHuman(event *cloudwatchlogs.Event) error {
+	streamName := event.LogStreamName
+	if len(streamName) > logStreamNameLen {
+		streamName = streamName[:logStreamNameLen]
+	}
+	line := fmt.Sprintf("%s %s", streamName, event.Message)
+	if lvl, ok := detectLogLevel(event.Message); ok {
+		if colorize, ok := levelColor[lvl]; ok {
+			line = colorize(line)
+		}
+	}
+	_, err := fmt.Fprintln(o.w, line)
+	return err
+}
+
+// envColorPalette is cycled through, by each environment's position in the
+// sorted envNames list, to color-code the per-line environment prefix in
+// --all-envs/multi-env mode.
+var envColorPalette = []func(string) string{
+	color.Emphasize,
+	color.Warning,
+	color.Faint,
+}
+
+func envColor(envNames []string, env string) func(string) string {
+	for i, name := range envNames {
+		if name == env {
+			return envColorPalette[i%len(envColorPalette)]
+		}
+	}
+	return nil
+}
+
+// writeEnvEvent renders a single event from a multi-environment tail,
+// prefixing it with its (color-coded) environment name in addition to the
+// truncated log stream name. envNames is the full, sorted set of tailed
+// environments so each one gets a stable color regardless of arrival order.
+func (o *svcLogsOpts) writeEnvEvent(envNames []string, env string, event *cloudwatchlogs.Event) error {
+	if o.shouldOutputJSON {
+		return o.writeEnvEventJSON(env, event)
+	}
+	streamName := event.LogStreamName
+	if len(streamName) > logStreamNameLen {
+		streamName = streamName[:logStreamNameLen]
+	}
+	prefix := fmt.Sprintf("[%s]", env)
+	if colorize := envColor(envNames, env); colorize != nil {
+		prefix = colorize(prefix)
+	}
+	_, err := fmt.Fprintf(o.w, "%s %s %s\n", prefix, streamName, event.Message)
+	return err
+}
+
+func (o *svcLogsOpts) writeEventJSON(event *cloudwatchlogs.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.w, "%s\n", data)
+	return err
+}
+
+// writeEnvEventJSON is writeEventJSON's --all-envs counterpart: it tags the
+// marshaled event with the environment it was tailed from, the same way the
+// human formatter prefixes every line with "[env]", so a --json consumer can
+// still tell events from different environments apart.
+func (o *svcLogsOpts) writeEnvEventJSON(env string, event *cloudwatchlogs.Event) error {
+	data, err := json.Marshal(struct {
+		*cloudwatchlogs.Event
+		Env string `json:"env"`
+	}{Event: event, Env: env})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.w, "%s\n", data)
+	return err
+}
+
+// buildSvcLogsCmd builds the command for displaying service logs in an application.
+func buildSvcLogsCmd() *cobra.Command {
+	vars := svcLogsVars{}
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Displays logs of a deployed service.",
+		Example: `
+  Displays logs of the service "my-svc" in environment "my-env".
+  /code $ copilot svc logs -n my-svc -e my-env
+  Displays logs in the last hour.
+  /code $ copilot svc logs --since 1h
+  Displays logs from 2006-01-02T15:04:05 to 2006-01-02T15:05:05.
+  /code $ copilot svc logs --start-time 2006-01-02T15:04:05+00:00 --end-time 2006-01-02T15:05:05+00:00
+  Only show FireLens application container logs at warn level or above.
+  /code $ copilot svc logs --container app --level warn
+  Only show lines matching a pattern.
+  /code $ copilot svc logs --grep "request_id=123"
+  Persist a long-running --follow session to a rotating local file.
+  /code $ copilot svc logs --follow --output-file ./svc.log --max-size 50 --max-files 5
+  Archive a long-running --follow session to S3 for later Athena/Insights queries.
+  /code $ copilot svc logs --follow --export-s3 s3://my-bucket/logs/my-svc
+  Call out unusual bursts of events or errors while following.
+  /code $ copilot svc logs --follow --highlight-anomalies`,
+		RunE: runCmdE(func(cmd *cobra.Command, args []string) error {
+			opts, err := newSvcLogOpts(vars)
+			if err != nil {
+				return err
+			}
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			if err := opts.Ask(); err != nil {
+				return err
+			}
+			return opts.Execute()
+		}),
+	}
+	// svcName, envName, since, humanStartTime, humanEndTime and
+	// shouldOutputJSON are registered as persistent flags: the query
+	// subcommand shares them rather than redeclaring its own copies.
+	cmd.PersistentFlags().StringVarP(&vars.svcName, nameFlag, nameFlagShort, "", svcFlagDescription)
+	cmd.PersistentFlags().StringVarP(&vars.envName, envFlag, envFlagShort, "", svcLogsEnvFlagDescription)
+	cmd.PersistentFlags().DurationVar(&vars.since, logsSinceFlag, 0, logsSinceFlagDescription)
+	cmd.PersistentFlags().StringVar(&vars.humanStartTime, logsStartTimeFlag, "", logsStartTimeFlagDescription)
+	cmd.PersistentFlags().StringVar(&vars.humanEndTime, logsEndTimeFlag, "", logsEndTimeFlagDescription)
+	cmd.PersistentFlags().BoolVar(&vars.shouldOutputJSON, jsonFlag, false, jsonFlagDescription)
+	cmd.Flags().BoolVar(&vars.follow, logsFollowFlag, false, logsFollowFlagDescription)
+	cmd.Flags().IntVar(&vars.limit, logsLimitFlag, defaultServiceLogLimit, logsLimitFlagDescription)
+	cmd.Flags().StringVar(&vars.container, logsContainerFlag, "", logsContainerFlagDescription)
+	cmd.Flags().StringVar(&vars.level, logsLevelFlag, "", logsLevelFlagDescription)
+	cmd.Flags().StringVar(&vars.grep, logsGrepFlag, "", logsGrepFlagDescription)
+	cmd.Flags().BoolVar(&vars.allEnvs, logsAllEnvsFlag, false, logsAllEnvsFlagDescription)
+	cmd.Flags().StringVar(&vars.outputFile, logsOutputFileFlag, "", logsOutputFileFlagDescription)
+	cmd.Flags().IntVar(&vars.maxSizeMB, logsMaxSizeFlag, defaultLogSinkMaxSizeMB, logsMaxSizeFlagDescription)
+	cmd.Flags().IntVar(&vars.maxFiles, logsMaxFilesFlag, defaultLogSinkMaxFiles, logsMaxFilesFlagDescription)
+	cmd.Flags().StringVar(&vars.exportS3, logsExportS3Flag, "", logsExportS3FlagDescription)
+	cmd.Flags().BoolVar(&vars.highlightAnomalies, logsHighlightAnomaliesFlag, false, logsHighlightAnomaliesFlagDescription)
+	cmd.Flags().DurationVar(&vars.window, logsWindowFlag, defaultAnomalyWindow, logsWindowFlagDescription)
+	cmd.Flags().Float64Var(&vars.sigma, logsSigmaFlag, defaultAnomalySigma, logsSigmaFlagDescription)
+	cmd.AddCommand(buildSvcLogsQueryCmd(&vars))
+	return cmd
+}
+
+// buildSvcLogsQueryCmd builds the "svc logs query" subcommand, which runs a
+// CloudWatch Logs Insights query instead of tailing raw events.
+func buildSvcLogsQueryCmd(vars *svcLogsVars) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "query",
+		Short: "Runs a CloudWatch Logs Insights query against a deployed service's logs.",
+		Example: `
+  Counts requests by status code over the last hour.
+  /code $ copilot svc logs query --since 1h --query-string "stats count(*) by status"
+  Runs a saved query from a file.
+  /code $ copilot svc logs query --query-file ./queries/top-paths.txt`,
+		RunE: runCmdE(func(cmd *cobra.Command, args []string) error {
+			opts, err := newSvcLogOpts(*vars)
+			if err != nil {
+				return err
+			}
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			if err := opts.Ask(); err != nil {
+				return err
+			}
+			return opts.Execute()
+		}),
+	}
+	cmd.Flags().StringVar(&vars.queryString, logsQueryStringFlag, "", logsQueryStringFlagDescription)
+	cmd.Flags().StringVar(&vars.queryFile, logsQueryFileFlag, "", logsQueryFileFlagDescription)
+	return cmd
+}
+
+const (
+	logsFollowFlag    = "follow"
+	logsSinceFlag     = "since"
+	logsStartTimeFlag = "start-time"
+	logsEndTimeFlag   = "end-time"
+	logsLimitFlag     = "limit"
+	logsContainerFlag = "container"
+	logsLevelFlag     = "level"
+	logsGrepFlag      = "grep"
+
+	logsQueryStringFlag = "query-string"
+	logsQueryFileFlag   = "query-file"
+
+	logsAllEnvsFlag = "all-envs"
+
+	logsOutputFileFlag = "output-file"
+	logsMaxSizeFlag    = "max-size"
+	logsMaxFilesFlag   = "max-files"
+	logsExportS3Flag   = "export-s3"
+
+	logsHighlightAnomaliesFlag = "highlight-anomalies"
+	logsWindowFlag             = "window"
+	logsSigmaFlag              = "sigma"
+
+	logsFollowFlagDescription    = "Specifies if the logs should be streamed."
+	logsSinceFlagDescription     = "Only return logs newer than a relative duration like 5s, 2m, or 3h. Defaults to all logs. Only one of start-time / since may be used."
+	logsStartTimeFlagDescription = `Only return logs after a specific date (RFC3339). Defaults to all logs. Only one of start-time / since may be used.`
+	logsEndTimeFlagDescription   = `Only return logs before a specific date (RFC3339). Defaults to all logs. Only one of end-time / follow may be used.`
+	logsLimitFlagDescription     = "Optional. The maximum number of log events returned."
+	logsContainerFlagDescription = "Optional. Only show logs from a single FireLens sidecar container."
+	logsLevelFlagDescription     = "Optional. Only show logs at or above this severity: debug, info, warn, error, fatal."
+	logsGrepFlagDescription      = "Optional. Only show logs whose message matches this regular expression."
+	svcLogsEnvFlagDescription    = "Name of the environment."
+
+	logsQueryStringFlagDescription = "A CloudWatch Logs Insights query string. Only one of query-string / query-file may be used."
+	logsQueryFileFlagDescription   = "A file containing a CloudWatch Logs Insights query. Only one of query-string / query-file may be used."
+
+	logsAllEnvsFlagDescription = "Optional. Tail the service's logs across every environment it's deployed to, merged by timestamp."
+
+	logsOutputFileFlagDescription = "Optional. Write logs to this file instead of stdout, gzip-rotating once --max-size is reached. Only one of output-file / export-s3 may be used."
+	logsMaxSizeFlagDescription    = "Optional. Maximum size in MB of --output-file before it's rotated and gzip-compressed."
+	logsMaxFilesFlagDescription   = "Optional. Number of rotated, gzip-compressed --output-file backups to keep."
+	logsExportS3FlagDescription   = "Optional. Export logs as newline-delimited JSON to this s3://bucket/prefix destination, partitioned by hour. Only one of output-file / export-s3 may be used."
+
+	logsHighlightAnomaliesFlagDescription = "Optional. Requires --follow. Highlights bursts of events or errors well above a log stream's recent baseline."
+	logsWindowFlagDescription             = "Optional. How much trailing history --highlight-anomalies' rolling baseline is built from."
+	logsSigmaFlagDescription              = "Optional. Number of standard deviations above the rolling mean --highlight-anomalies flags as an anomaly."
+)
+
+const (
+	// defaultLogSinkMaxSizeMB is the default --max-size before --output-file rotates.
+	defaultLogSinkMaxSizeMB = 100
+	// defaultLogSinkMaxFiles is the default number of rotated --output-file backups kept.
+	defaultLogSinkMaxFiles = 5
+)
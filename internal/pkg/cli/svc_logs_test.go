@@ -5,8 +5,13 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +20,7 @@ import (
 	"github.com/aws/copilot-cli/internal/pkg/term/selector"
 
 	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,14 +39,22 @@ func TestSvcLogs_Validate(t *testing.T) {
 		mockBadEndTime   = "badEndTime"
 	)
 	testCases := map[string]struct {
-		inputApp       string
-		inputSvc       string
-		inputLimit     int
-		inputFollow    bool
-		inputEnvName   string
-		inputStartTime string
-		inputEndTime   string
-		inputSince     time.Duration
+		inputApp                string
+		inputSvc                string
+		inputLimit              int
+		inputFollow             bool
+		inputEnvName            string
+		inputStartTime          string
+		inputEndTime            string
+		inputSince              time.Duration
+		inputLevel              string
+		inputGrep               string
+		inputOutputFile         string
+		inputExportS3           string
+		inputHighlightAnomalies bool
+		inputAllEnvs            bool
+		inputQueryString        string
+		inputQueryFile          string
 
 		mockstore func(m *mocks.Mockstore)
 
@@ -114,6 +128,66 @@ func TestSvcLogs_Validate(t *testing.T) {
 
 			wantedError: fmt.Errorf("--limit 10001 is out-of-bounds, value must be between 1 and 10000"),
 		},
+		"returns error if invalid level flag value": {
+			inputLimit: 10,
+			inputLevel: "critical",
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("invalid argument critical for \"--level\" flag: level must be one of debug, info, warn, error, fatal"),
+		},
+		"returns error if invalid grep flag value": {
+			inputLimit: 10,
+			inputGrep:  "(unterminated",
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("invalid argument (unterminated for \"--grep\" flag: error parsing regexp: missing closing ): `(unterminated`"),
+		},
+		"returns error if output-file and export-s3 flags are set together": {
+			inputLimit:      10,
+			inputOutputFile: "svc.log",
+			inputExportS3:   "s3://my-bucket/logs",
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("only one of --output-file or --export-s3 may be used"),
+		},
+		"returns error if highlight-anomalies is set without follow": {
+			inputLimit:              10,
+			inputHighlightAnomalies: true,
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("--highlight-anomalies requires --follow"),
+		},
+		"returns error if query-string and query-file flags are set together": {
+			inputLimit:       10,
+			inputQueryString: "stats count(*) by status",
+			inputQueryFile:   "query.txt",
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("only one of --query-string or --query-file may be used"),
+		},
+		"returns error if all-envs is set alongside query-string": {
+			inputLimit:       10,
+			inputAllEnvs:     true,
+			inputQueryString: "stats count(*) by status",
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("--all-envs cannot be combined with --query-string or --query-file"),
+		},
+		"returns error if all-envs is set alongside query-file": {
+			inputLimit:     10,
+			inputAllEnvs:   true,
+			inputQueryFile: "query.txt",
+
+			mockstore: func(m *mocks.Mockstore) {},
+
+			wantedError: fmt.Errorf("--all-envs cannot be combined with --query-string or --query-file"),
+		},
 	}
 
 	for name, tc := range testCases {
@@ -126,13 +200,21 @@ func TestSvcLogs_Validate(t *testing.T) {
 
 			svcLogs := &svcLogsOpts{
 				svcLogsVars: svcLogsVars{
-					follow:         tc.inputFollow,
-					limit:          tc.inputLimit,
-					envName:        tc.inputEnvName,
-					humanStartTime: tc.inputStartTime,
-					humanEndTime:   tc.inputEndTime,
-					since:          tc.inputSince,
-					svcName:        tc.inputSvc,
+					follow:             tc.inputFollow,
+					limit:              tc.inputLimit,
+					envName:            tc.inputEnvName,
+					humanStartTime:     tc.inputStartTime,
+					humanEndTime:       tc.inputEndTime,
+					since:              tc.inputSince,
+					svcName:            tc.inputSvc,
+					level:              tc.inputLevel,
+					grep:               tc.inputGrep,
+					outputFile:         tc.inputOutputFile,
+					exportS3:           tc.inputExportS3,
+					highlightAnomalies: tc.inputHighlightAnomalies,
+					allEnvs:            tc.inputAllEnvs,
+					queryString:        tc.inputQueryString,
+					queryFile:          tc.inputQueryFile,
 					GlobalOpts: &GlobalOpts{
 						appName: tc.inputApp,
 					},
@@ -290,11 +372,16 @@ firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "WARN some warnin
 `
 	logEventsJSONString := "{\"logStreamName\":\"firelens_log_router/fcfe4ab8043841c08162318e5ad805f1\",\"ingestionTime\":0,\"message\":\"10.0.0.00 - - [01/Jan/1970 01:01:01] \\\"GET / HTTP/1.1\\\" 200 -\",\"timestamp\":0}\n{\"logStreamName\":\"firelens_log_router/fcfe4ab8043841c08162318e5ad805f1\",\"ingestionTime\":0,\"message\":\"10.0.0.00 - - [01/Jan/1970 01:01:01] \\\"FATA some error\\\" - -\",\"timestamp\":0}\n{\"logStreamName\":\"firelens_log_router/fcfe4ab8043841c08162318e5ad805f1\",\"ingestionTime\":0,\"message\":\"10.0.0.00 - - [01/Jan/1970 01:01:01] \\\"WARN some warning\\\" - -\",\"timestamp\":0}\n"
 	testCases := map[string]struct {
-		inputApp     string
-		inputSvc     string
-		inputFollow  bool
-		inputEnvName string
-		inputJSON    bool
+		inputApp         string
+		inputSvc         string
+		inputFollow      bool
+		inputEnvName     string
+		inputJSON        bool
+		inputLevel       string
+		inputGrep        string
+		inputContainer   string
+		inputQueryString string
+		inputEnvNames    []string
 
 		mockcwlogService func(ctrl *gomock.Controller) map[string]cwlogService
 
@@ -385,6 +472,185 @@ firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "GET / HTTP/1.1"
 
 			wantedError: fmt.Errorf("some error"),
 		},
+		"with level flag set": {
+			inputApp:     "mockApp",
+			inputSvc:     "mockSvc",
+			inputEnvName: "mockEnv",
+			inputLevel:   "warn",
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				m := mocks.NewMockcwlogService(ctrl)
+				cwlogServices := make(map[string]cwlogService)
+				m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+					Return(&cloudwatchlogs.LogEventsOutput{
+						Events: logEvents,
+					}, nil)
+
+				cwlogServices["mockEnv"] = m
+				return cwlogServices
+			},
+
+			wantedError: nil,
+			wantedContent: `firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "FATA some error" - -
+firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "WARN some warning" - -
+`,
+		},
+		"with grep flag set": {
+			inputApp:     "mockApp",
+			inputSvc:     "mockSvc",
+			inputEnvName: "mockEnv",
+			inputGrep:    "warning",
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				m := mocks.NewMockcwlogService(ctrl)
+				cwlogServices := make(map[string]cwlogService)
+				m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+					Return(&cloudwatchlogs.LogEventsOutput{
+						Events: logEvents,
+					}, nil)
+
+				cwlogServices["mockEnv"] = m
+				return cwlogServices
+			},
+
+			wantedError: nil,
+			wantedContent: `firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "WARN some warning" - -
+`,
+		},
+		"with container flag set to a container not present in the stream": {
+			inputApp:       "mockApp",
+			inputSvc:       "mockSvc",
+			inputEnvName:   "mockEnv",
+			inputContainer: "sidecar",
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				m := mocks.NewMockcwlogService(ctrl)
+				cwlogServices := make(map[string]cwlogService)
+				m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+					Return(&cloudwatchlogs.LogEventsOutput{
+						Events: logEvents,
+					}, nil)
+
+				cwlogServices["mockEnv"] = m
+				return cwlogServices
+			},
+
+			wantedError:   nil,
+			wantedContent: ``,
+		},
+		"with container flag set to a container present in the stream": {
+			inputApp:       "mockApp",
+			inputSvc:       "mockSvc",
+			inputEnvName:   "mockEnv",
+			inputContainer: "app",
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				m := mocks.NewMockcwlogService(ctrl)
+				cwlogServices := make(map[string]cwlogService)
+				m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+					Return(&cloudwatchlogs.LogEventsOutput{
+						Events: []*cloudwatchlogs.Event{
+							{
+								LogStreamName: "firelens_log_router/app/fcfe4ab8043841c08162318e5ad805f1",
+								Message:       "hello from app",
+							},
+							{
+								LogStreamName: "firelens_log_router/sidecar/fcfe4ab8043841c08162318e5ad805f1",
+								Message:       "hello from sidecar",
+							},
+						},
+					}, nil)
+
+				cwlogServices["mockEnv"] = m
+				return cwlogServices
+			},
+
+			wantedError:   nil,
+			wantedContent: "firelens_log_router/app/f hello from app\n",
+		},
+		"with query-string flag set": {
+			inputApp:         "mockApp",
+			inputSvc:         "mockSvc",
+			inputEnvName:     "mockEnv",
+			inputQueryString: "stats count(*) by status",
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				m := mocks.NewMockcwlogService(ctrl)
+				cwlogServices := make(map[string]cwlogService)
+				m.EXPECT().StartLogInsightsQuery(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), "stats count(*) by status", gomock.Any(), gomock.Any()).
+					Return("mockQueryID", nil)
+				m.EXPECT().GetLogInsightsQueryResults("mockQueryID").Return(&cloudwatchlogs.QueryResultsOutput{
+					Status: cloudwatchlogs.QueryStatusComplete,
+					Results: [][]cloudwatchlogs.QueryResultField{
+						{
+							{Field: "status", Value: "200"},
+							{Field: "count", Value: "42"},
+						},
+					},
+				}, nil)
+				cwlogServices["mockEnv"] = m
+				return cwlogServices
+			},
+
+			wantedError:   nil,
+			wantedContent: "status=200\tcount=42\n",
+		},
+		"with query-string flag set and the query fails": {
+			inputApp:         "mockApp",
+			inputSvc:         "mockSvc",
+			inputEnvName:     "mockEnv",
+			inputQueryString: "stats count(*) by status",
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				m := mocks.NewMockcwlogService(ctrl)
+				cwlogServices := make(map[string]cwlogService)
+				m.EXPECT().StartLogInsightsQuery(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), "stats count(*) by status", gomock.Any(), gomock.Any()).
+					Return("mockQueryID", nil)
+				m.EXPECT().GetLogInsightsQueryResults("mockQueryID").Return(&cloudwatchlogs.QueryResultsOutput{
+					Status: cloudwatchlogs.QueryStatusFailed,
+				}, nil)
+				cwlogServices["mockEnv"] = m
+				return cwlogServices
+			},
+
+			wantedError: errors.New("query mockQueryID ended with status Failed"),
+		},
+		"with multiple envs, merges events across envs by timestamp": {
+			inputApp:      "mockApp",
+			inputSvc:      "mockSvc",
+			inputEnvNames: []string{"env2", "env1"},
+
+			mockcwlogService: func(ctrl *gomock.Controller) map[string]cwlogService {
+				cwlogServices := make(map[string]cwlogService)
+
+				env1Svc := mocks.NewMockcwlogService(ctrl)
+				env1Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env1", "mockSvc"), make(map[string]int64), gomock.Any()).
+					Return(&cloudwatchlogs.LogEventsOutput{
+						Events: []*cloudwatchlogs.Event{
+							{LogStreamName: "firelens_log_router/env1stream", Message: "env1 first", Timestamp: 100},
+							{LogStreamName: "firelens_log_router/env1stream", Message: "env1 second", Timestamp: 300},
+						},
+					}, nil)
+				cwlogServices["env1"] = env1Svc
+
+				env2Svc := mocks.NewMockcwlogService(ctrl)
+				env2Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env2", "mockSvc"), make(map[string]int64), gomock.Any()).
+					Return(&cloudwatchlogs.LogEventsOutput{
+						Events: []*cloudwatchlogs.Event{
+							{LogStreamName: "firelens_log_router/env2stream", Message: "env2 first", Timestamp: 200},
+						},
+					}, nil)
+				cwlogServices["env2"] = env2Svc
+
+				return cwlogServices
+			},
+
+			wantedError: nil,
+			wantedContent: `[env1] firelens_log_router/env1s env1 first
+[env2] firelens_log_router/env2s env2 first
+[env1] firelens_log_router/env1s env1 second
+`,
+		},
 	}
 
 	for name, tc := range testCases {
@@ -399,6 +665,9 @@ firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "GET / HTTP/1.1"
 					envName:          tc.inputEnvName,
 					svcName:          tc.inputSvc,
 					shouldOutputJSON: tc.inputJSON,
+					container:        tc.inputContainer,
+					queryString:      tc.inputQueryString,
+					envNames:         tc.inputEnvNames,
 					GlobalOpts: &GlobalOpts{
 						appName: tc.inputApp,
 					},
@@ -406,6 +675,15 @@ firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "GET / HTTP/1.1"
 				initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
 				cwlogsSvc:     tc.mockcwlogService(ctrl),
 				w:             b,
+				sleep:         func(time.Duration) {},
+			}
+			if tc.inputLevel != "" {
+				lvl, err := normalizeLogLevel(tc.inputLevel)
+				require.NoError(t, err)
+				svcLogs.minLevel = &lvl
+			}
+			if tc.inputGrep != "" {
+				svcLogs.grepRe = regexp.MustCompile(tc.inputGrep)
 			}
 
 			// WHEN
@@ -421,3 +699,608 @@ firelens_log_router/fcfe4 10.0.0.00 - - [01/Jan/1970 01:01:01] "GET / HTTP/1.1"
 		})
 	}
 }
+
+func TestSvcLogs_Execute_OutputFileSink(t *testing.T) {
+	// GIVEN a --output-file destination
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockcwlogService(ctrl)
+	m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: []*cloudwatchlogs.Event{
+				{LogStreamName: "firelens_log_router/fcfe4ab8043841c08162318e5ad805f1", Message: "hello"},
+			},
+		}, nil)
+
+	fs := afero.NewMemMapFs()
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			envName:    "mockEnv",
+			svcName:    "mockSvc",
+			outputFile: "svc.log",
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"mockEnv": m},
+		fs:            fs,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN
+	require.NoError(t, err)
+	content, err := afero.ReadFile(fs, "svc.log")
+	require.NoError(t, err)
+	require.Equal(t, "firelens_log_router/fcfe4 hello\n", string(content))
+}
+
+func TestSvcLogs_Execute_QueryFile(t *testing.T) {
+	// GIVEN a --query-file pointing at a file containing the query
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockcwlogService(ctrl)
+	m.EXPECT().StartLogInsightsQuery(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), "stats count(*) by status", gomock.Any(), gomock.Any()).
+		Return("mockQueryID", nil)
+	m.EXPECT().GetLogInsightsQueryResults("mockQueryID").Return(&cloudwatchlogs.QueryResultsOutput{
+		Status: cloudwatchlogs.QueryStatusComplete,
+		Results: [][]cloudwatchlogs.QueryResultField{
+			{{Field: "status", Value: "200"}, {Field: "count", Value: "42"}},
+		},
+	}, nil)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "query.txt", []byte("stats count(*) by status"), 0644))
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			envName:    "mockEnv",
+			svcName:    "mockSvc",
+			queryFile:  "query.txt",
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"mockEnv": m},
+		fs:            fs,
+		w:             b,
+		sleep:         func(time.Duration) {},
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN the query read from the file ran and its results were written
+	require.NoError(t, err)
+	require.Equal(t, "status=200\tcount=42\n", b.String())
+}
+
+func TestSvcLogs_Execute_QueryFile_ReadError(t *testing.T) {
+	// GIVEN a --query-file that doesn't exist
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			envName:    "mockEnv",
+			svcName:    "mockSvc",
+			queryFile:  "missing.txt",
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"mockEnv": mocks.NewMockcwlogService(ctrl)},
+		fs:            afero.NewMemMapFs(),
+		w:             &bytes.Buffer{},
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN the unreadable file surfaces as an error instead of panicking or
+	// submitting an empty query
+	require.EqualError(t, err, "read query file missing.txt: open missing.txt: file does not exist")
+}
+
+func TestSvcLogs_Execute_AllEnvs(t *testing.T) {
+	// GIVEN --all-envs is set and the service is deployed to two environments
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDeployStore := mocks.NewMockdeployedEnvLister(ctrl)
+	mockDeployStore.EXPECT().ListDeployedEnvironments("mockApp", "mockSvc").Return([]string{"env2", "env1"}, nil)
+
+	env1Svc := mocks.NewMockcwlogService(ctrl)
+	env1Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env1", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: []*cloudwatchlogs.Event{
+				{LogStreamName: "firelens_log_router/env1stream", Message: "env1 first", Timestamp: 100},
+			},
+		}, nil)
+	env2Svc := mocks.NewMockcwlogService(ctrl)
+	env2Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env2", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: []*cloudwatchlogs.Event{
+				{LogStreamName: "firelens_log_router/env2stream", Message: "env2 first", Timestamp: 200},
+			},
+		}, nil)
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			svcName:    "mockSvc",
+			allEnvs:    true,
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		deployStore:   mockDeployStore,
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"env1": env1Svc, "env2": env2Svc},
+		w:             b,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN the environments the deploy store returned (sorted) were tailed and merged
+	require.NoError(t, err)
+	require.Equal(t, `[env1] firelens_log_router/env1s env1 first
+[env2] firelens_log_router/env2s env2 first
+`, b.String())
+}
+
+func TestSvcLogs_Execute_AllEnvs_NotDeployedEverywhere(t *testing.T) {
+	// GIVEN the app has more environments configured than the service is
+	// actually deployed to
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDeployStore := mocks.NewMockdeployedEnvLister(ctrl)
+	mockDeployStore.EXPECT().ListDeployedEnvironments("mockApp", "mockSvc").Return([]string{"env1"}, nil)
+
+	env1Svc := mocks.NewMockcwlogService(ctrl)
+	env1Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env1", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: []*cloudwatchlogs.Event{
+				{LogStreamName: "firelens_log_router/env1stream", Message: "env1 only", Timestamp: 100},
+			},
+		}, nil)
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			svcName:    "mockSvc",
+			allEnvs:    true,
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		deployStore:   mockDeployStore,
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"env1": env1Svc},
+		w:             b,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN only the environment the service is actually deployed to is tailed,
+	// even though the app may have other environments configured
+	require.NoError(t, err)
+	require.Equal(t, "[env1] firelens_log_router/env1s env1 only\n", b.String())
+}
+
+func TestSvcLogs_Execute_AllEnvs_TolerantOfPerEnvError(t *testing.T) {
+	// GIVEN one of several deployed environments fails to return log events
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDeployStore := mocks.NewMockdeployedEnvLister(ctrl)
+	mockDeployStore.EXPECT().ListDeployedEnvironments("mockApp", "mockSvc").Return([]string{"env1", "env2"}, nil)
+
+	env1Svc := mocks.NewMockcwlogService(ctrl)
+	env1Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env1", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(nil, errors.New("access denied"))
+	env2Svc := mocks.NewMockcwlogService(ctrl)
+	env2Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env2", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: []*cloudwatchlogs.Event{
+				{LogStreamName: "firelens_log_router/env2stream", Message: "env2 first", Timestamp: 200},
+			},
+		}, nil)
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			svcName:    "mockSvc",
+			allEnvs:    true,
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		deployStore:   mockDeployStore,
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"env1": env1Svc, "env2": env2Svc},
+		w:             b,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN env1's error is tolerated and env2's events still get tailed
+	require.NoError(t, err)
+	require.Equal(t, "[env2] firelens_log_router/env2s env2 first\n", b.String())
+}
+
+func TestSvcLogs_Execute_AllEnvs_AllEnvsFail(t *testing.T) {
+	// GIVEN every deployed environment fails to return log events, without --follow
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDeployStore := mocks.NewMockdeployedEnvLister(ctrl)
+	mockDeployStore.EXPECT().ListDeployedEnvironments("mockApp", "mockSvc").Return([]string{"env1", "env2"}, nil)
+
+	env1Svc := mocks.NewMockcwlogService(ctrl)
+	env1Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env1", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(nil, errors.New("access denied"))
+	env2Svc := mocks.NewMockcwlogService(ctrl)
+	env2Svc.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "env2", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(nil, errors.New("access denied"))
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			svcName:    "mockSvc",
+			allEnvs:    true,
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		deployStore:   mockDeployStore,
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"env1": env1Svc, "env2": env2Svc},
+		w:             b,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN the command reports an error instead of silently exiting 0
+	require.EqualError(t, err, "tail any of environment(s) env1, env2")
+	require.Equal(t, "", b.String())
+}
+
+// fakeS3Uploader is an in-memory s3Uploader used to test the --export-s3 sink
+// without making real AWS calls.
+type fakeS3Uploader struct {
+	uploads []fakeS3Upload
+}
+
+type fakeS3Upload struct {
+	bucket, key, body string
+}
+
+func (f *fakeS3Uploader) Upload(bucket, key string, body io.Reader) error {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	f.uploads = append(f.uploads, fakeS3Upload{bucket: bucket, key: key, body: string(data)})
+	return nil
+}
+
+func TestSvcLogs_Execute_S3ExportSink(t *testing.T) {
+	// GIVEN a --export-s3 destination
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockcwlogService(ctrl)
+	m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: []*cloudwatchlogs.Event{
+				{LogStreamName: "firelens_log_router/fcfe4ab8043841c08162318e5ad805f1", Message: "hello"},
+			},
+		}, nil)
+
+	uploader := &fakeS3Uploader{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			envName:    "mockEnv",
+			svcName:    "mockSvc",
+			exportS3:   "s3://my-bucket/logs/my-svc",
+			GlobalOpts: &GlobalOpts{appName: "mockApp"},
+		},
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"mockEnv": m},
+		newS3Uploader: func() (s3Uploader, error) { return uploader, nil },
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN
+	require.NoError(t, err)
+	require.Len(t, uploader.uploads, 1, "Close should flush the batch buffered during Execute")
+	require.Equal(t, "my-bucket", uploader.uploads[0].bucket)
+	require.Contains(t, uploader.uploads[0].key, "logs/my-svc/year=")
+	require.Equal(t, "{\"logStreamName\":\"firelens_log_router/fcfe4ab8043841c08162318e5ad805f1\",\"ingestionTime\":0,\"message\":\"hello\",\"timestamp\":0}\n", uploader.uploads[0].body)
+}
+
+func TestFileSink_Rotate(t *testing.T) {
+	// GIVEN a fileSink that rotates after 10 bytes and keeps 2 backups
+	fs := afero.NewMemMapFs()
+	sink, err := newFileSink(fs, "svc.log", 10, 2)
+	require.NoError(t, err)
+
+	// WHEN a write pushes the file past maxSizeBytes
+	_, err = sink.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	_, err = sink.Write([]byte("more"))
+	require.NoError(t, err)
+
+	// THEN the prior contents were gzip-rotated out and the new write landed
+	// in a fresh file
+	rotated, err := afero.Exists(fs, "svc.log.1.gz")
+	require.NoError(t, err)
+	require.True(t, rotated, "expected the prior contents to be rotated and gzip-compressed")
+
+	content, err := afero.ReadFile(fs, "svc.log")
+	require.NoError(t, err)
+	require.Equal(t, "more", string(content))
+}
+
+func TestS3Sink(t *testing.T) {
+	// GIVEN an s3Sink with a 10-byte batch size
+	uploader := &fakeS3Uploader{}
+	fixedNow := func() time.Time { return time.Date(2024, time.January, 2, 3, 0, 0, 0, time.UTC) }
+	sink := newS3Sink(uploader, "my-bucket", "logs/my-svc/", 10, fixedNow)
+
+	// WHEN enough events are written to exceed the batch size
+	_, err := sink.Write([]byte(`{"a":1}` + "\n"))
+	require.NoError(t, err)
+	_, err = sink.Write([]byte(`{"b":2}` + "\n"))
+	require.NoError(t, err)
+
+	// THEN the first batch was flushed under an hour-partitioned key
+	require.Len(t, uploader.uploads, 1, "writing past the batch size should flush the prior batch")
+	require.Equal(t, "my-bucket", uploader.uploads[0].bucket)
+	require.Equal(t, "logs/my-svc/year=2024/month=01/day=02/hour=03/", uploader.uploads[0].key[:len("logs/my-svc/year=2024/month=01/day=02/hour=03/")])
+	require.Equal(t, `{"a":1}`+"\n", uploader.uploads[0].body)
+
+	// WHEN Close is called
+	require.NoError(t, sink.Close())
+
+	// THEN the remaining buffered batch is flushed too
+	require.Len(t, uploader.uploads, 2)
+	require.Equal(t, `{"b":2}`+"\n", uploader.uploads[1].body)
+}
+
+func TestParseS3URI(t *testing.T) {
+	testCases := map[string]struct {
+		inputURI string
+
+		wantedBucket string
+		wantedPrefix string
+		wantedError  error
+	}{
+		"bucket and prefix": {
+			inputURI:     "s3://my-bucket/logs/my-svc",
+			wantedBucket: "my-bucket",
+			wantedPrefix: "logs/my-svc/",
+		},
+		"bucket and prefix with trailing slash": {
+			inputURI:     "s3://my-bucket/logs/my-svc/",
+			wantedBucket: "my-bucket",
+			wantedPrefix: "logs/my-svc/",
+		},
+		"bucket only": {
+			inputURI:     "s3://my-bucket",
+			wantedBucket: "my-bucket",
+			wantedPrefix: "",
+		},
+		"missing scheme": {
+			inputURI:    "my-bucket/logs",
+			wantedError: fmt.Errorf("invalid argument my-bucket/logs for \"--export-s3\" flag: must be a s3:// URI"),
+		},
+		"missing bucket": {
+			inputURI:    "s3:///logs",
+			wantedError: fmt.Errorf("invalid argument s3:///logs for \"--export-s3\" flag: missing bucket name"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bucket, prefix, err := parseS3URI(tc.inputURI)
+
+			if tc.wantedError != nil {
+				require.EqualError(t, err, tc.wantedError.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantedBucket, bucket)
+			require.Equal(t, tc.wantedPrefix, prefix)
+		})
+	}
+}
+
+func TestWelford(t *testing.T) {
+	// GIVEN the classic population [2, 4, 4, 4, 5, 5, 7, 9] (mean 5, stddev 2)
+	var w welford
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		w.add(x)
+	}
+	require.InDelta(t, 5.0, w.mean, 1e-9)
+	require.InDelta(t, 2.0, w.stddev(), 1e-9)
+
+	// WHEN the two outermost samples are removed
+	w.remove(2)
+	w.remove(9)
+
+	// THEN the running mean matches the remaining [4, 4, 4, 5, 5, 7]
+	require.Equal(t, 6, w.n)
+	require.InDelta(t, 29.0/6.0, w.mean, 1e-9)
+}
+
+// burstEvents returns errorBurstThreshold+1 error-level events on a single
+// stream, one second apart, which is exactly enough to cross
+// observeBurst's threshold on the last event.
+func burstEvents(streamName string) []*cloudwatchlogs.Event {
+	events := make([]*cloudwatchlogs.Event, errorBurstThreshold+1)
+	for i := range events {
+		events[i] = &cloudwatchlogs.Event{
+			LogStreamName: streamName,
+			Timestamp:     int64(i) * 1000,
+			Message:       `{"level":"error","msg":"boom"}`,
+		}
+	}
+	return events
+}
+
+func TestSvcLogs_Execute_HighlightAnomalies(t *testing.T) {
+	// GIVEN --highlight-anomalies --follow and a stream that crosses the
+	// error-burst threshold within a single page of events
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockcwlogService(ctrl)
+	m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: burstEvents("firelens_log_router/fcfe4ab8043841c08162318e5ad805f1"),
+		}, nil)
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			envName:            "mockEnv",
+			svcName:            "mockSvc",
+			follow:             true,
+			highlightAnomalies: true,
+			GlobalOpts:         &GlobalOpts{appName: "mockApp"},
+		},
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"mockEnv": m},
+		w:             b,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN a banner line is printed immediately before the event that
+	// crossed the threshold, and no earlier event got one
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	require.Len(t, lines, errorBurstThreshold+2, "one banner plus one line per event")
+	require.Contains(t, lines[errorBurstThreshold], "anomaly: ", "banner should precede the event that crossed the threshold")
+	require.Contains(t, lines[errorBurstThreshold], "error-level events")
+}
+
+func TestSvcLogs_Execute_HighlightAnomalies_JSON(t *testing.T) {
+	// GIVEN --highlight-anomalies --follow --json and the same error burst
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	streamName := "firelens_log_router/fcfe4ab8043841c08162318e5ad805f1"
+	m := mocks.NewMockcwlogService(ctrl)
+	m.EXPECT().TaskLogEvents(fmt.Sprintf(logGroupNamePattern, "mockApp", "mockEnv", "mockSvc"), make(map[string]int64), gomock.Any()).
+		Return(&cloudwatchlogs.LogEventsOutput{
+			Events: burstEvents(streamName),
+		}, nil)
+
+	b := &bytes.Buffer{}
+	svcLogs := &svcLogsOpts{
+		svcLogsVars: svcLogsVars{
+			envName:            "mockEnv",
+			svcName:            "mockSvc",
+			follow:             true,
+			highlightAnomalies: true,
+			shouldOutputJSON:   true,
+			GlobalOpts:         &GlobalOpts{appName: "mockApp"},
+		},
+		initCwLogsSvc: func(*svcLogsOpts, string) error { return nil },
+		cwlogsSvc:     map[string]cwlogService{"mockEnv": m},
+		w:             b,
+	}
+
+	// WHEN
+	err := svcLogs.Execute()
+
+	// THEN the banner is emitted as a synthetic "kind":"anomaly" JSON object
+	// ahead of the event that triggered it
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	require.Len(t, lines, errorBurstThreshold+2)
+	var banner struct {
+		Kind    string `json:"kind"`
+		Stream  string `json:"stream"`
+		Message string `json:"message"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[errorBurstThreshold]), &banner))
+	require.Equal(t, "anomaly", banner.Kind)
+	require.Equal(t, streamName, banner.Stream)
+}
+
+func TestSvcLogsOpts_AnomalyWindowAndSigma(t *testing.T) {
+	// GIVEN --window and --sigma are left unset
+	defaults := &svcLogsOpts{}
+	// THEN anomalyWindow/anomalySigma fall back to their defaults
+	require.Equal(t, defaultAnomalyWindow, defaults.anomalyWindow())
+	require.Equal(t, defaultAnomalySigma, defaults.anomalySigma())
+
+	// GIVEN --window and --sigma are set
+	overridden := &svcLogsOpts{svcLogsVars: svcLogsVars{window: 2 * time.Minute, sigma: 5}}
+	// THEN anomalyWindow/anomalySigma return the overrides instead
+	require.Equal(t, 2*time.Minute, overridden.anomalyWindow())
+	require.Equal(t, float64(5), overridden.anomalySigma())
+}
+
+func TestAnomalyDetector_ObserveRate(t *testing.T) {
+	// GIVEN a detector whose baseline warms up over the first two buckets
+	d := newAnomalyDetector(3*anomalyBucketWidth, 3)
+
+	events := []*cloudwatchlogs.Event{
+		{LogStreamName: "stream", Timestamp: 0, Message: "one"},
+		{LogStreamName: "stream", Timestamp: 10000, Message: "one"},
+		{LogStreamName: "stream", Timestamp: 20000, Message: "one"},
+		{LogStreamName: "stream", Timestamp: 20000, Message: "one"},
+	}
+
+	// WHEN the events are observed in order, the last one doubling its
+	// bucket's count over the now-established baseline
+	var banners [][]anomalyBanner
+	for _, e := range events {
+		banners = append(banners, d.observe(e))
+	}
+
+	// THEN only the bucket that spiked past the baseline is flagged
+	for i := 0; i < 3; i++ {
+		require.Empty(t, banners[i], "no anomaly expected while the baseline warms up")
+	}
+	require.Len(t, banners[3], 1, "a bucket at double its baseline should be flagged")
+	require.Contains(t, banners[3][0].message, "stream")
+}
+
+func TestAnomalyDetector_ObserveBurst(t *testing.T) {
+	// GIVEN a detector and a stream about to cross the error-burst threshold
+	d := newAnomalyDetector(defaultAnomalyWindow, defaultAnomalySigma)
+
+	// WHEN errorBurstThreshold+1 error-level events land one second apart
+	var banners [][]anomalyBanner
+	for i := 0; i <= errorBurstThreshold; i++ {
+		banners = append(banners, d.observe(&cloudwatchlogs.Event{
+			LogStreamName: "stream",
+			Timestamp:     int64(i) * 1000,
+			Message:       `{"level":"error","msg":"boom"}`,
+		}))
+	}
+
+	// THEN only the event that crosses the threshold is flagged
+	for i := 0; i < errorBurstThreshold; i++ {
+		require.Empty(t, banners[i], "shouldn't flag a burst before crossing the threshold")
+	}
+	require.Len(t, banners[errorBurstThreshold], 1, "crossing the burst threshold should emit exactly one banner")
+	require.Contains(t, banners[errorBurstThreshold][0].message, "error-level events")
+
+	// WHEN another error lands while still bursting
+	again := d.observe(&cloudwatchlogs.Event{
+		LogStreamName: "stream",
+		Timestamp:     int64(errorBurstThreshold+1) * 1000,
+		Message:       `{"level":"error","msg":"boom"}`,
+	})
+
+	// THEN the banner doesn't fire again until the burst clears
+	require.Empty(t, again, "burst banner should only fire once while still bursting")
+}